@@ -1,74 +1,85 @@
 package auth
 
 import (
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"math/rand"
+	"net"
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 	"tinyauth/internal/docker"
+	"tinyauth/internal/events"
 	"tinyauth/internal/ldap"
+	"tinyauth/internal/ratelimit"
+	"tinyauth/internal/session"
 	"tinyauth/internal/types"
 	"tinyauth/internal/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/sessions"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Auth struct {
-	Config        types.AuthConfig
-	Docker        *docker.Docker
-	LoginAttempts map[string]*types.LoginAttempt
-	LoginMutex    sync.RWMutex
-	Store         *sessions.CookieStore
-	LDAP          *ldap.LDAP
+	Config    types.AuthConfig
+	Docker    *docker.Docker
+	RateLimit ratelimit.Limiter
+	Events    events.Sink
+	Sessions  session.Store
+	LDAP      ldap.Directory
 }
 
-func NewAuth(config types.AuthConfig, docker *docker.Docker, ldap *ldap.LDAP) *Auth {
-	// Create cookie store
-	store := sessions.NewCookieStore([]byte(config.HMACSecret), []byte(config.EncryptionSecret))
+func NewAuth(config types.AuthConfig, docker *docker.Docker, ldap ldap.Directory) (*Auth, error) {
+	store, err := session.NewStore(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session store: %w", err)
+	}
 
-	// Configure cookie store
-	store.Options = &sessions.Options{
-		Path:     "/",
-		MaxAge:   config.SessionExpiry,
-		Secure:   config.CookieSecure,
-		HttpOnly: true,
-		Domain:   fmt.Sprintf(".%s", config.Domain),
+	limiter, err := ratelimit.NewLimiter(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rate limiter: %w", err)
 	}
 
 	return &Auth{
-		Config:        config,
-		Docker:        docker,
-		LoginAttempts: make(map[string]*types.LoginAttempt),
-		Store:         store,
-		LDAP:          ldap,
-	}
+		Config:    config,
+		Docker:    docker,
+		RateLimit: limiter,
+		Events:    events.LogSink{},
+		Sessions:  store,
+		LDAP:      ldap,
+	}, nil
 }
 
-func (auth *Auth) GetSession(c *gin.Context) (*sessions.Session, error) {
-	// Get session
-	session, err := auth.Store.Get(c.Request, auth.Config.SessionCookieName)
-
-	if err != nil {
-		log.Warn().Err(err).Msg("Invalid session, clearing cookie and retrying")
-
-		// Delete the session cookie if there is an error
-		c.SetCookie(auth.Config.SessionCookieName, "", -1, "/", fmt.Sprintf(".%s", auth.Config.Domain), auth.Config.CookieSecure, true)
-
-		// Try to get the session again
-		session, err = auth.Store.Get(c.Request, auth.Config.SessionCookieName)
+// LogoutUserEverywhere revokes every active session belonging to username,
+// regardless of which device/browser created it. Backed by
+// SessionStore.DeleteAllForUser, so it is only effective when
+// SessionBackend is "bolt" - the cookie backend has no server-side record
+// to revoke.
+func (auth *Auth) LogoutUserEverywhere(username string) error {
+	return auth.Sessions.DeleteAllForUser(username)
+}
 
-		if err != nil {
-			// If we still can't get the session, log the error and return nil
-			log.Error().Err(err).Msg("Failed to get session")
-			return nil, err
-		}
+// RefreshLDAPDirectory triggers a manual reload of the LDAP directory
+// snapshot, for modes that keep one (e.g. an admin endpoint to pick up
+// directory changes immediately instead of waiting for the refresh timer).
+func (auth *Auth) RefreshLDAPDirectory() error {
+	if auth.LDAP == nil {
+		return nil
 	}
+	return auth.LDAP.Refresh()
+}
 
-	return session, nil
+// LDAPDirectoryStats reports the directory cache size and last sync time,
+// for metrics. Modes with no cache (e.g. "direct") report size -1 and a
+// zero time.
+func (auth *Auth) LDAPDirectoryStats() (cacheSize int, lastSync time.Time) {
+	if auth.LDAP == nil {
+		return -1, time.Time{}
+	}
+	return auth.LDAP.CacheSize(), auth.LDAP.LastSync()
 }
 
 func (auth *Auth) SearchUser(username string) types.UserSearch {
@@ -105,7 +116,35 @@ func (auth *Auth) SearchUser(username string) types.UserSearch {
 	return types.UserSearch{}
 }
 
-func (auth *Auth) VerifyUser(search types.UserSearch, password string) bool {
+// VerifyUser authenticates search with password and, for LDAP users,
+// resolves the user's current group membership. Groups are always
+// re-fetched from the directory at login time rather than trusted from a
+// stale session, since group membership can change between logins.
+//
+// On any failure it sleeps a random 100-200ms (antiBruteForceSleep) so
+// failed attempts don't leak timing differences between backends or
+// between valid and invalid usernames, and it always emits a structured
+// LoginAttempt event through auth.Events.
+func (auth *Auth) VerifyUser(c *gin.Context, search types.UserSearch, password string) (success bool, groups []string, err error) {
+	defer func() {
+		if !success {
+			antiBruteForceSleep()
+		}
+
+		// Keyed by both username and client IP, so an attacker probing
+		// many usernames from one IP is still locked out even though no
+		// single username crosses LoginMaxRetries on its own.
+		auth.recordLoginAttempts(c, search.Username, success)
+
+		auth.Events.Emit(events.LoginAttempt{
+			Success:    success,
+			Username:   search.Username,
+			RemoteAddr: auth.ClientIdentifier(c),
+			Provider:   search.Type,
+			Timestamp:  time.Now(),
+		})
+	}()
+
 	// Authenticate the user based on the type
 	switch search.Type {
 	case "local":
@@ -113,7 +152,7 @@ func (auth *Auth) VerifyUser(search types.UserSearch, password string) bool {
 		user := auth.GetLocalUser(search.Username)
 
 		// Check if password is correct
-		return auth.CheckPassword(user, password)
+		return auth.CheckPassword(user, password), nil, nil
 	case "ldap":
 		// If LDAP is configured, bind to the LDAP server with the user DN and password
 		if auth.LDAP != nil {
@@ -123,29 +162,51 @@ func (auth *Auth) VerifyUser(search types.UserSearch, password string) bool {
 			err := auth.LDAP.Bind(search.Username, password)
 			if err != nil {
 				log.Warn().Err(err).Str("username", search.Username).Msg("Failed to bind to LDAP")
-				return false
+				return false, nil, nil
 			}
 
-			// If bind is successful, rebind with the LDAP bind user
-			err = auth.LDAP.Bind(auth.LDAP.Config.BindDN, auth.LDAP.Config.BindPassword)
+			// Fetch the user's current group membership now that the
+			// password has been verified. Groups.connect() rebinds as the
+			// service account itself, not the user DN bound above.
+			groups, err := auth.LDAP.Groups(search.Username)
 			if err != nil {
-				log.Error().Err(err).Msg("Failed to rebind with service account after user authentication")
-				// Consider closing the connection or creating a new one
-				return false
+				log.Warn().Err(err).Str("username", search.Username).Msg("Failed to fetch LDAP groups")
+			}
+
+			if !auth.LDAPGroupsAllowed(groups) {
+				log.Warn().Str("username", search.Username).Strs("groups", groups).Msg("User is not a member of any required LDAP group")
+				return false, groups, nil
 			}
 
 			log.Debug().Str("username", search.Username).Msg("LDAP authentication successful")
 
 			// Return true if the bind was successful
-			return true
+			return true, groups, nil
 		}
 	default:
 		log.Warn().Str("type", search.Type).Msg("Unknown user type for authentication")
-		return false
+		return false, nil, nil
 	}
 
 	// If no user found or authentication failed, return false
 	log.Warn().Str("username", search.Username).Msg("User authentication failed")
+	return false, nil, nil
+}
+
+// LDAPGroupsAllowed checks groups against the global LDAPRequiredGroups
+// gate. If no required groups are configured, every LDAP user is allowed
+// through to the per-resource checks in LDAPGroup.
+func (auth *Auth) LDAPGroupsAllowed(groups []string) bool {
+	if len(auth.Config.LDAPRequiredGroups) == 0 {
+		return true
+	}
+
+	for _, group := range groups {
+		if utils.CheckWhitelist(strings.Join(auth.Config.LDAPRequiredGroups, ","), group) {
+			return true
+		}
+	}
+
 	return false
 }
 
@@ -171,192 +232,160 @@ func (auth *Auth) CheckPassword(user types.User, password string) bool {
 
 // IsAccountLocked checks if a username or IP is locked due to too many failed login attempts
 func (auth *Auth) IsAccountLocked(identifier string) (bool, int) {
-	auth.LoginMutex.RLock()
-	defer auth.LoginMutex.RUnlock()
+	return auth.RateLimit.IsLocked(identifier)
+}
 
-	// Return false if rate limiting is not configured
-	if auth.Config.LoginMaxRetries <= 0 || auth.Config.LoginTimeout <= 0 {
-		return false, 0
-	}
+// RecordLoginAttempt records a login attempt for rate limiting
+func (auth *Auth) RecordLoginAttempt(identifier string, success bool) {
+	auth.RateLimit.RecordAttempt(identifier, success)
+}
+
+// IsLoginBlocked checks both the username and the client IP behind c
+// against the rate limiter, so an attacker probing many usernames from one
+// IP is still locked out even though no single username ever crosses
+// LoginMaxRetries on its own. It returns the longer of the two remaining
+// lockout durations.
+func (auth *Auth) IsLoginBlocked(c *gin.Context, username string) (bool, int) {
+	userLocked, userRemaining := auth.IsAccountLocked(username)
+	ipLocked, ipRemaining := auth.IsAccountLocked(auth.ClientIdentifier(c))
 
-	// Check if the identifier exists in the map
-	attempt, exists := auth.LoginAttempts[identifier]
-	if !exists {
+	if !userLocked && !ipLocked {
 		return false, 0
 	}
 
-	// If account is locked, check if lock time has expired
-	if attempt.LockedUntil.After(time.Now()) {
-		// Calculate remaining lockout time in seconds
-		remaining := int(time.Until(attempt.LockedUntil).Seconds())
-		return true, remaining
+	if ipRemaining > userRemaining {
+		return true, ipRemaining
 	}
 
-	// Lock has expired
-	return false, 0
+	return true, userRemaining
 }
 
-// RecordLoginAttempt records a login attempt for rate limiting
-func (auth *Auth) RecordLoginAttempt(identifier string, success bool) {
-	// Skip if rate limiting is not configured
-	if auth.Config.LoginMaxRetries <= 0 || auth.Config.LoginTimeout <= 0 {
-		return
-	}
+// recordLoginAttempts updates both the username and client-IP buckets for
+// a single login attempt, the pairing IsLoginBlocked checks.
+func (auth *Auth) recordLoginAttempts(c *gin.Context, username string, success bool) {
+	auth.RecordLoginAttempt(username, success)
+	auth.RecordLoginAttempt(auth.ClientIdentifier(c), success)
+}
 
-	auth.LoginMutex.Lock()
-	defer auth.LoginMutex.Unlock()
+// ClientIdentifier derives the rate-limit/event identifier for the client
+// behind c: the direct TCP peer address, or the client IP carried in
+// X-Forwarded-For/X-Real-Ip when that peer is a configured trusted proxy.
+// Unlike relying on gin's own SetTrustedProxies, this is driven entirely by
+// Config.TrustedProxies so it behaves the same regardless of how the
+// engine was set up.
+func (auth *Auth) ClientIdentifier(c *gin.Context) string {
+	peer := directPeerIP(c)
 
-	// Get current attempt record or create a new one
-	attempt, exists := auth.LoginAttempts[identifier]
-	if !exists {
-		attempt = &types.LoginAttempt{}
-		auth.LoginAttempts[identifier] = attempt
+	if !auth.isTrustedProxy(peer) {
+		return peer
 	}
 
-	// Update last attempt time
-	attempt.LastAttempt = time.Now()
+	if forwarded := c.Request.Header.Get("X-Forwarded-For"); forwarded != "" {
+		if client, ok := rightmostUntrustedIP(forwarded, auth.isTrustedProxy); ok {
+			return client
+		}
+	}
 
-	// If successful login, reset failed attempts
-	if success {
-		attempt.FailedAttempts = 0
-		attempt.LockedUntil = time.Time{} // Reset lock time
-		return
+	if real := c.Request.Header.Get("X-Real-Ip"); real != "" {
+		return real
 	}
 
-	// Increment failed attempts
-	attempt.FailedAttempts++
+	return peer
+}
 
-	// If max retries reached, lock the account
-	if attempt.FailedAttempts >= auth.Config.LoginMaxRetries {
-		attempt.LockedUntil = time.Now().Add(time.Duration(auth.Config.LoginTimeout) * time.Second)
-		log.Warn().Str("identifier", identifier).Int("timeout", auth.Config.LoginTimeout).Msg("Account locked due to too many failed login attempts")
+// rightmostUntrustedIP walks an X-Forwarded-For chain from right to left,
+// skipping entries that are themselves trusted proxies, and returns the
+// first (i.e. rightmost) one that isn't. The left-most entries are
+// client-controlled and cannot be trusted: each proxy only ever appends to
+// the right, so the real client is the rightmost hop not already accounted
+// for by a trusted proxy.
+func rightmostUntrustedIP(forwarded string, isTrustedProxy func(string) bool) (string, bool) {
+	parts := strings.Split(forwarded, ",")
+
+	for i := len(parts) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(parts[i])
+		if ip == "" {
+			continue
+		}
+		if !isTrustedProxy(ip) {
+			return ip, true
+		}
 	}
-}
 
-func (auth *Auth) EmailWhitelisted(emailSrc string) bool {
-	return utils.CheckWhitelist(auth.Config.OauthWhitelist, emailSrc)
+	return "", false
 }
 
-func (auth *Auth) CreateSessionCookie(c *gin.Context, data *types.SessionCookie) error {
-	log.Debug().Msg("Creating session cookie")
+func (auth *Auth) isTrustedProxy(ip string) bool {
+	for _, proxy := range auth.Config.TrustedProxies {
+		if ok, err := utils.FilterIP(proxy, ip); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
 
-	// Get session
-	session, err := auth.GetSession(c)
+func directPeerIP(c *gin.Context) string {
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to get session")
-		return err
+		return c.Request.RemoteAddr
 	}
 
-	log.Debug().Msg("Setting session cookie")
+	return host
+}
 
-	// Calculate expiry
-	var sessionExpiry int
+// antiBruteForceSleep adds a small random delay on authentication failure,
+// so failed attempts for valid vs. invalid usernames, or across backends,
+// take a similar amount of time and can't be used to enumerate accounts.
+func antiBruteForceSleep() {
+	jitter := 100 + rand.Intn(101) // 100-200ms
+	time.Sleep(time.Duration(jitter) * time.Millisecond)
+}
 
-	if data.TotpPending {
-		sessionExpiry = 3600
-	} else {
-		sessionExpiry = auth.Config.SessionExpiry
-	}
+func (auth *Auth) EmailWhitelisted(emailSrc string) bool {
+	return utils.CheckWhitelist(auth.Config.OauthWhitelist, emailSrc)
+}
 
-	// Set data
-	session.Values["username"] = data.Username
-	session.Values["name"] = data.Name
-	session.Values["email"] = data.Email
-	session.Values["provider"] = data.Provider
-	session.Values["expiry"] = time.Now().Add(time.Duration(sessionExpiry) * time.Second).Unix()
-	session.Values["totpPending"] = data.TotpPending
-	session.Values["oauthGroups"] = data.OAuthGroups
+func (auth *Auth) CreateSessionCookie(c *gin.Context, data *types.SessionCookie) error {
+	log.Debug().Msg("Creating session cookie")
 
-	// Save session
-	err = session.Save(c.Request, c.Writer)
+	err := auth.Sessions.Create(c, data)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to save session")
 		return err
 	}
 
-	// Return nil
 	return nil
 }
 
 func (auth *Auth) DeleteSessionCookie(c *gin.Context) error {
 	log.Debug().Msg("Deleting session cookie")
 
-	// Get session
-	session, err := auth.GetSession(c)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to get session")
-		return err
-	}
-
-	// Delete all values in the session
-	for key := range session.Values {
-		delete(session.Values, key)
-	}
-
-	// Save session
-	err = session.Save(c.Request, c.Writer)
+	err := auth.Sessions.Delete(c)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to save session")
+		log.Error().Err(err).Msg("Failed to delete session")
 		return err
 	}
 
-	// Return nil
 	return nil
 }
 
 func (auth *Auth) GetSessionCookie(c *gin.Context) (types.SessionCookie, error) {
 	log.Debug().Msg("Getting session cookie")
 
-	// Get session
-	session, err := auth.GetSession(c)
+	data, ok, err := auth.Sessions.Get(c)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get session")
 		return types.SessionCookie{}, err
 	}
 
-	log.Debug().Msg("Got session")
-
-	// Get data from session
-	username, usernameOk := session.Values["username"].(string)
-	email, emailOk := session.Values["email"].(string)
-	name, nameOk := session.Values["name"].(string)
-	provider, providerOK := session.Values["provider"].(string)
-	expiry, expiryOk := session.Values["expiry"].(int64)
-	totpPending, totpPendingOk := session.Values["totpPending"].(bool)
-	oauthGroups, oauthGroupsOk := session.Values["oauthGroups"].(string)
-
-	if !usernameOk || !providerOK || !expiryOk || !totpPendingOk || !emailOk || !nameOk || !oauthGroupsOk {
-		log.Warn().Msg("Session cookie is invalid")
-
-		// If any data is missing, delete the session cookie
-		auth.DeleteSessionCookie(c)
-
-		// Return empty cookie
-		return types.SessionCookie{}, nil
-	}
-
-	// Check if the cookie has expired
-	if time.Now().Unix() > expiry {
-		log.Warn().Msg("Session cookie expired")
-
-		// If it has, delete it
-		auth.DeleteSessionCookie(c)
-
-		// Return empty cookie
+	if !ok {
 		return types.SessionCookie{}, nil
 	}
 
-	log.Debug().Str("username", username).Str("provider", provider).Int64("expiry", expiry).Bool("totpPending", totpPending).Str("name", name).Str("email", email).Str("oauthGroups", oauthGroups).Msg("Parsed cookie")
+	log.Debug().Str("username", data.Username).Str("provider", data.Provider).Bool("totpPending", data.TotpPending).Str("name", data.Name).Str("email", data.Email).Str("oauthGroups", data.OAuthGroups).Msg("Parsed session")
 
-	// Return the cookie
-	return types.SessionCookie{
-		Username:    username,
-		Name:        name,
-		Email:       email,
-		Provider:    provider,
-		TotpPending: totpPending,
-		OAuthGroups: oauthGroups,
-	}, nil
+	return data, nil
 }
 
 func (auth *Auth) UserAuthConfigured() bool {
@@ -407,6 +436,36 @@ func (auth *Auth) OAuthGroup(c *gin.Context, context types.UserContext, labels t
 	return false
 }
 
+func (auth *Auth) LDAPGroup(c *gin.Context, context types.UserContext, labels types.Labels) bool {
+	// Check if groups are required
+	if labels.LDAP.Groups == "" {
+		return true
+	}
+
+	// Check if we are dealing with an LDAP user
+	if context.Provider != "ldap" {
+		log.Debug().Msg("Not an LDAP user, skipping group check")
+		return true
+	}
+
+	// Split the groups by comma (resolved fresh at login time, not trusted from a stale cookie)
+	ldapGroups := strings.Split(context.LDAPGroups, ",")
+
+	// For every group check if it is in the required groups
+	for _, group := range ldapGroups {
+		if utils.CheckWhitelist(labels.LDAP.Groups, group) {
+			log.Debug().Str("group", group).Msg("Group is in required groups")
+			return true
+		}
+	}
+
+	// No groups matched
+	log.Debug().Msg("No groups matched")
+
+	// Return false
+	return false
+}
+
 func (auth *Auth) AuthEnabled(c *gin.Context, labels types.Labels) (bool, error) {
 	// Get headers
 	uri := c.Request.Header.Get("X-Forwarded-Uri")
@@ -452,6 +511,156 @@ func (auth *Auth) GetBasicAuth(c *gin.Context) *types.User {
 	}
 }
 
+const bearerPrefix = "Bearer "
+
+// tokenStore returns the session store as a session.TokenStore, if the
+// configured backend supports persisting API tokens (currently only
+// SessionBackend=bolt, since tokens live in the same server-side store as
+// sessions).
+func (auth *Auth) tokenStore() (session.TokenStore, bool) {
+	store, ok := auth.Sessions.(session.TokenStore)
+	return store, ok
+}
+
+func extractAPIToken(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, bearerPrefix) {
+		return strings.TrimPrefix(header, bearerPrefix)
+	}
+
+	return c.GetHeader("X-Api-Key")
+}
+
+func hashAPIToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPIToken authenticates c against an Authorization: Bearer <token> or
+// X-Api-Key header, alongside GetBasicAuth and GetSessionCookie. On success
+// it synthesizes a UserContext so Docker-label ACLs via ResourceAllowed
+// apply the same way they do for session/basic-auth users.
+func (auth *Auth) CheckAPIToken(c *gin.Context) (types.UserContext, bool) {
+	secret := extractAPIToken(c)
+	if secret == "" {
+		return types.UserContext{}, false
+	}
+
+	store, ok := auth.tokenStore()
+	if !ok {
+		log.Warn().Msg("API token presented but SessionBackend is not \"bolt\", rejecting")
+		return types.UserContext{}, false
+	}
+
+	hash := hashAPIToken(secret)
+
+	token, found, err := store.LookupToken(hash)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to look up API token")
+		return types.UserContext{}, false
+	}
+
+	if !found {
+		return types.UserContext{}, false
+	}
+
+	if !token.ExpiresAt.IsZero() && time.Now().After(token.ExpiresAt) {
+		log.Warn().Str("id", token.ID).Str("owner", token.Owner).Msg("API token expired")
+		return types.UserContext{}, false
+	}
+
+	if err := store.TouchToken(token.ID, time.Now()); err != nil {
+		log.Warn().Err(err).Str("id", token.ID).Msg("Failed to update API token last-used time")
+	}
+
+	return types.UserContext{
+		Username: token.Owner,
+		Provider: "apitoken",
+		Scopes:   token.Scopes,
+	}, true
+}
+
+// APITokenHasScope checks whether context (as produced by CheckAPIToken)
+// carries scope. Session/basic-auth users have no scopes and are always
+// allowed, since scopes only restrict what an API token can do.
+func (auth *Auth) APITokenHasScope(context types.UserContext, scope string) bool {
+	if context.Provider != "apitoken" {
+		return true
+	}
+
+	for _, s := range context.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CreateAPIToken mints a new token for owner with the given scopes and
+// optional ttl (zero means it never expires), returning the plaintext
+// secret once - only its sha256 hash is ever persisted.
+func (auth *Auth) CreateAPIToken(owner string, scopes []string, ttl time.Duration) (string, types.APIToken, error) {
+	store, ok := auth.tokenStore()
+	if !ok {
+		return "", types.APIToken{}, fmt.Errorf("API tokens require SessionBackend=bolt")
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return "", types.APIToken{}, fmt.Errorf("failed to generate API token: %w", err)
+	}
+
+	id, err := randomToken()
+	if err != nil {
+		return "", types.APIToken{}, fmt.Errorf("failed to generate API token id: %w", err)
+	}
+
+	token := types.APIToken{
+		ID:     id,
+		Hash:   hashAPIToken(secret),
+		Owner:  owner,
+		Scopes: scopes,
+	}
+
+	if ttl > 0 {
+		token.ExpiresAt = time.Now().Add(ttl)
+	}
+
+	if err := store.CreateToken(&token); err != nil {
+		return "", types.APIToken{}, fmt.Errorf("failed to persist API token: %w", err)
+	}
+
+	return secret, token, nil
+}
+
+// RevokeAPIToken deletes the token with the given ID.
+func (auth *Auth) RevokeAPIToken(id string) error {
+	store, ok := auth.tokenStore()
+	if !ok {
+		return fmt.Errorf("API tokens require SessionBackend=bolt")
+	}
+
+	return store.RevokeToken(id)
+}
+
+// ListAPITokens returns every token belonging to owner.
+func (auth *Auth) ListAPITokens(owner string) ([]types.APIToken, error) {
+	store, ok := auth.tokenStore()
+	if !ok {
+		return nil, fmt.Errorf("API tokens require SessionBackend=bolt")
+	}
+
+	return store.ListTokens(owner)
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := cryptorand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
 func (auth *Auth) CheckIP(c *gin.Context, labels types.Labels) bool {
 	// Get the IP address from the request
 	ip := c.ClientIP()
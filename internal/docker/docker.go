@@ -0,0 +1,6 @@
+package docker
+
+// Docker is the handle Auth keeps for the Docker integration. In this
+// package it is an empty type: it holds no Docker client and has no
+// methods, so it resolves no tinyauth.* labels itself.
+type Docker struct{}
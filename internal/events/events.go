@@ -0,0 +1,38 @@
+// Package events defines the pluggable sink login attempts are reported
+// through, so operators can pipe them to logs, webhooks, or Prometheus
+// counters without Auth knowing which.
+package events
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// LoginAttempt is a single structured login event.
+type LoginAttempt struct {
+	Success    bool
+	Username   string
+	RemoteAddr string
+	Provider   string
+	Timestamp  time.Time
+}
+
+// Sink receives login events as they happen.
+type Sink interface {
+	Emit(event LoginAttempt)
+}
+
+// LogSink is the default Sink: it writes the event to the regular tinyauth
+// log output.
+type LogSink struct{}
+
+func (LogSink) Emit(event LoginAttempt) {
+	log.Info().
+		Bool("success", event.Success).
+		Str("username", event.Username).
+		Str("remoteAddr", event.RemoteAddr).
+		Str("provider", event.Provider).
+		Time("timestamp", event.Timestamp).
+		Msg("Login attempt")
+}
@@ -0,0 +1,270 @@
+package ldap
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Config holds the settings required to connect to and search an LDAP
+// directory.
+type Config struct {
+	Address      string
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	SearchFilter string
+	Insecure     bool
+
+	// GroupSearchBase is the subtree groups are searched under. Defaults
+	// to BaseDN when empty.
+	GroupSearchBase string
+
+	// GroupMembershipFilter is an LDAP filter template with a single %s
+	// placeholder for the authenticated user's DN, e.g.
+	// "(member=%s)" (nginx-sso/Grafana style).
+	GroupMembershipFilter string
+
+	// Mode selects the Directory implementation: "direct" (default) hits
+	// the LDAP server on every SearchUser call, "memory" snapshots the
+	// user subtree into an in-memory index on a timer.
+	Mode string
+
+	// RefreshInterval controls how often "memory" mode resnapshots the
+	// directory. Defaults to 5 minutes when zero.
+	RefreshInterval time.Duration
+
+	// UserObjectFilter enumerates every user entry under BaseDN, used to
+	// build the "memory" mode snapshot. Defaults to "(objectClass=person)".
+	UserObjectFilter string
+
+	// AltAttributes are additional attributes (beyond uid) that "memory"
+	// mode indexes users by, e.g. "mail".
+	AltAttributes []string
+}
+
+// Directory is the lookup/auth surface Auth needs from an LDAP backend.
+// LDAP itself implements Directory directly ("direct" mode); MemorySearcher
+// wraps an LDAP client to serve SearchUser from a cached snapshot instead.
+//
+// Refresh/CacheSize/LastSync are part of the interface (not just
+// MemorySearcher) so callers can trigger a manual refresh or expose cache
+// metrics without type-asserting down to a concrete mode. For "direct"
+// mode, which has no cache, they are harmless no-ops: Refresh does
+// nothing, CacheSize is -1, and LastSync is the zero time.
+type Directory interface {
+	Search(username string) (string, error)
+	Bind(dn string, password string) error
+	Groups(userDN string) ([]string, error)
+	GetConfig() Config
+
+	// Refresh reloads the directory snapshot, if the mode keeps one.
+	Refresh() error
+
+	// CacheSize returns the number of entries in the directory snapshot,
+	// or -1 if the mode doesn't cache.
+	CacheSize() int
+
+	// LastSync returns when the snapshot was last refreshed, or the zero
+	// time if the mode doesn't cache.
+	LastSync() time.Time
+}
+
+// NewDirectory builds the Directory implementation selected by config.Mode.
+func NewDirectory(config Config) (Directory, error) {
+	switch config.Mode {
+	case "", "direct":
+		return NewLDAP(config), nil
+	case "memory":
+		return NewMemorySearcher(config)
+	default:
+		return nil, fmt.Errorf("unknown ldap mode %q", config.Mode)
+	}
+}
+
+// LDAP is a thin client around a single LDAP directory, used to resolve
+// usernames to bind DNs and to authenticate users via bind. It is the
+// "direct" Directory implementation: every call hits the server.
+type LDAP struct {
+	Config Config
+}
+
+// NewLDAP constructs an LDAP client for the given config.
+func NewLDAP(config Config) *LDAP {
+	return &LDAP{Config: config}
+}
+
+// GetConfig returns the config the client was built with, e.g. so callers
+// can rebind as the service account after authenticating a user.
+func (l *LDAP) GetConfig() Config {
+	return l.Config
+}
+
+// Refresh is a no-op in direct mode: every Search already hits the server,
+// there is no snapshot to reload.
+func (l *LDAP) Refresh() error {
+	return nil
+}
+
+// CacheSize reports -1 in direct mode, since it doesn't cache anything.
+func (l *LDAP) CacheSize() int {
+	return -1
+}
+
+// LastSync returns the zero time in direct mode, since it doesn't cache
+// anything.
+func (l *LDAP) LastSync() time.Time {
+	return time.Time{}
+}
+
+func (l *LDAP) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(l.Config.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(l.Config.BindDN, l.Config.BindPassword); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// Search resolves a username to its DN using the configured search filter.
+func (l *LDAP) Search(username string) (string, error) {
+	conn, err := l.connect()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	filter := fmt.Sprintf(l.Config.SearchFilter, ldap.EscapeFilter(username))
+
+	req := ldap.NewSearchRequest(
+		l.Config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter,
+		[]string{"dn"},
+		nil,
+	)
+
+	res, err := conn.Search(req)
+	if err != nil {
+		return "", err
+	}
+
+	if len(res.Entries) != 1 {
+		return "", fmt.Errorf("expected exactly one entry for %s, got %d", username, len(res.Entries))
+	}
+
+	return res.Entries[0].DN, nil
+}
+
+// Groups resolves the groups userDN currently belongs to, using
+// GroupMembershipFilter against GroupSearchBase (falling back to BaseDN).
+// It is called fresh on every login rather than cached, since group
+// membership can change between logins.
+func (l *LDAP) Groups(userDN string) ([]string, error) {
+	if l.Config.GroupMembershipFilter == "" {
+		return nil, nil
+	}
+
+	conn, err := l.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	base := l.Config.GroupSearchBase
+	if base == "" {
+		base = l.Config.BaseDN
+	}
+
+	filter := fmt.Sprintf(l.Config.GroupMembershipFilter, ldap.EscapeFilter(userDN))
+
+	req := ldap.NewSearchRequest(
+		base,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter,
+		[]string{"cn"},
+		nil,
+	)
+
+	res, err := conn.Search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]string, 0, len(res.Entries))
+	for _, entry := range res.Entries {
+		if cn := entry.GetAttributeValue("cn"); cn != "" {
+			groups = append(groups, cn)
+		}
+	}
+
+	return groups, nil
+}
+
+// Entry is a single directory entry returned by AllUsers, as needed by
+// MemorySearcher to build its in-memory index.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// AllUsers enumerates every entry under BaseDN matching UserObjectFilter,
+// returning uid and the configured AltAttributes for each so callers can
+// index by more than one attribute.
+func (l *LDAP) AllUsers() ([]Entry, error) {
+	conn, err := l.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	filter := l.Config.UserObjectFilter
+	if filter == "" {
+		filter = "(objectClass=person)"
+	}
+
+	attrs := append([]string{"uid"}, l.Config.AltAttributes...)
+
+	req := ldap.NewSearchRequest(
+		l.Config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter,
+		attrs,
+		nil,
+	)
+
+	res, err := conn.Search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(res.Entries))
+	for _, e := range res.Entries {
+		attributes := make(map[string][]string, len(attrs))
+		for _, attr := range attrs {
+			if vals := e.GetAttributeValues(attr); len(vals) > 0 {
+				attributes[attr] = vals
+			}
+		}
+		entries = append(entries, Entry{DN: e.DN, Attributes: attributes})
+	}
+
+	return entries, nil
+}
+
+// Bind authenticates against the directory with the given DN and password.
+func (l *LDAP) Bind(dn string, password string) error {
+	conn, err := ldap.DialURL(l.Config.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Bind(dn, password)
+}
@@ -0,0 +1,131 @@
+package ldap
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const defaultRefreshInterval = 5 * time.Minute
+
+// MemorySearcher is the "memory" Directory mode: it periodically snapshots
+// the user subtree into an in-memory index keyed by uid and any configured
+// alternate attributes (e.g. mail), and answers Search from that index
+// instead of hitting the LDAP server on every login. Bind and Groups still
+// go straight to the directory, since they need a live connection.
+type MemorySearcher struct {
+	ldap            *LDAP
+	refreshInterval time.Duration
+
+	mu       sync.RWMutex
+	index    map[string]string // uid/alt attribute value -> DN
+	lastSync time.Time
+}
+
+// NewMemorySearcher builds a MemorySearcher and performs the initial
+// snapshot synchronously, so the cache is warm before it serves traffic.
+func NewMemorySearcher(config Config) (*MemorySearcher, error) {
+	refreshInterval := config.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = defaultRefreshInterval
+	}
+
+	ms := &MemorySearcher{
+		ldap:            NewLDAP(config),
+		refreshInterval: refreshInterval,
+		index:           make(map[string]string),
+	}
+
+	if err := ms.Refresh(); err != nil {
+		return nil, err
+	}
+
+	go ms.refreshLoop()
+
+	return ms, nil
+}
+
+// Refresh reloads the snapshot from the directory. It can be called
+// manually (e.g. from an admin endpoint) in addition to the automatic
+// timer, to pick up directory changes immediately.
+func (ms *MemorySearcher) Refresh() error {
+	entries, err := ms.ldap.AllUsers()
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		for _, vals := range entry.Attributes {
+			for _, val := range vals {
+				index[val] = entry.DN
+			}
+		}
+	}
+
+	ms.mu.Lock()
+	ms.index = index
+	ms.lastSync = time.Now()
+	ms.mu.Unlock()
+
+	log.Debug().Int("users", len(index)).Msg("Refreshed in-memory LDAP directory snapshot")
+
+	return nil
+}
+
+func (ms *MemorySearcher) refreshLoop() {
+	ticker := time.NewTicker(ms.refreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := ms.Refresh(); err != nil {
+			log.Warn().Err(err).Msg("Failed to refresh in-memory LDAP directory snapshot, keeping stale cache")
+		}
+	}
+}
+
+// Search answers from the in-memory index, falling back to a direct LDAP
+// search on a cache miss so a user added since the last refresh (or a
+// temporarily stale snapshot) still works.
+func (ms *MemorySearcher) Search(username string) (string, error) {
+	ms.mu.RLock()
+	dn, ok := ms.index[username]
+	ms.mu.RUnlock()
+
+	if ok {
+		return dn, nil
+	}
+
+	log.Debug().Str("username", username).Msg("Cache miss in in-memory LDAP directory, falling back to direct search")
+
+	return ms.ldap.Search(username)
+}
+
+func (ms *MemorySearcher) Bind(dn string, password string) error {
+	return ms.ldap.Bind(dn, password)
+}
+
+func (ms *MemorySearcher) Groups(userDN string) ([]string, error) {
+	return ms.ldap.Groups(userDN)
+}
+
+func (ms *MemorySearcher) GetConfig() Config {
+	return ms.ldap.GetConfig()
+}
+
+// CacheSize returns the number of entries currently in the in-memory
+// index, for metrics.
+func (ms *MemorySearcher) CacheSize() int {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return len(ms.index)
+}
+
+// LastSync returns when the index was last successfully refreshed, for
+// metrics.
+func (ms *MemorySearcher) LastSync() time.Time {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return ms.lastSync
+}
@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"tinyauth/internal/types"
+
+	"github.com/rs/zerolog/log"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	attemptsBucket = "login_attempts"
+
+	evictInterval = 10 * time.Minute
+
+	// staleAfter bounds how long an identifier with no new attempts is
+	// kept around, so the bucket doesn't grow unbounded with one-off
+	// usernames/IPs that never come back.
+	staleAfter = 24 * time.Hour
+)
+
+// BoltLimiter persists FailedAttempts/LockedUntil per identifier to a
+// local BoltDB file, so lockouts survive a tinyauth restart instead of
+// resetting every deploy.
+type BoltLimiter struct {
+	config types.AuthConfig
+	db     *bbolt.DB
+}
+
+// NewBoltLimiter opens (creating if necessary) the BoltDB file configured
+// via RateLimitBoltPath and starts the background stale-entry evictor.
+func NewBoltLimiter(config types.AuthConfig) (*BoltLimiter, error) {
+	if config.RateLimitBoltPath == "" {
+		return nil, fmt.Errorf("RateLimitBoltPath must be set when RateLimitBackend is \"bolt\"")
+	}
+
+	db, err := bbolt.Open(config.RateLimitBoltPath, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rate limit store: %w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(attemptsBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize rate limit store bucket: %w", err)
+	}
+
+	limiter := &BoltLimiter{config: config, db: db}
+	go limiter.evictLoop()
+
+	return limiter, nil
+}
+
+func (l *BoltLimiter) get(identifier string) (types.LoginAttempt, bool, error) {
+	var attempt types.LoginAttempt
+	var found bool
+
+	err := l.db.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket([]byte(attemptsBucket)).Get([]byte(identifier))
+		if raw == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(raw, &attempt)
+	})
+
+	return attempt, found, err
+}
+
+func (l *BoltLimiter) put(identifier string, attempt types.LoginAttempt) error {
+	payload, err := json.Marshal(attempt)
+	if err != nil {
+		return err
+	}
+
+	return l.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(attemptsBucket)).Put([]byte(identifier), payload)
+	})
+}
+
+func (l *BoltLimiter) IsLocked(identifier string) (bool, int) {
+	if l.config.LoginMaxRetries <= 0 || l.config.LoginTimeout <= 0 {
+		return false, 0
+	}
+
+	attempt, found, err := l.get(identifier)
+	if err != nil {
+		log.Warn().Err(err).Str("identifier", identifier).Msg("Failed to read rate limit record, allowing attempt")
+		return false, 0
+	}
+
+	if !found {
+		return false, 0
+	}
+
+	if attempt.LockedUntil.After(time.Now()) {
+		return true, int(time.Until(attempt.LockedUntil).Seconds())
+	}
+
+	return false, 0
+}
+
+func (l *BoltLimiter) RecordAttempt(identifier string, success bool) {
+	if l.config.LoginMaxRetries <= 0 || l.config.LoginTimeout <= 0 {
+		return
+	}
+
+	attempt, _, err := l.get(identifier)
+	if err != nil {
+		log.Warn().Err(err).Str("identifier", identifier).Msg("Failed to read rate limit record")
+	}
+
+	attempt.LastAttempt = time.Now()
+
+	if success {
+		attempt.FailedAttempts = 0
+		attempt.LockedUntil = time.Time{}
+	} else {
+		attempt.FailedAttempts++
+
+		if attempt.FailedAttempts >= l.config.LoginMaxRetries {
+			attempt.LockedUntil = time.Now().Add(time.Duration(l.config.LoginTimeout) * time.Second)
+			log.Warn().Str("identifier", identifier).Int("timeout", l.config.LoginTimeout).Msg("Account locked due to too many failed login attempts")
+		}
+	}
+
+	if err := l.put(identifier, attempt); err != nil {
+		log.Warn().Err(err).Str("identifier", identifier).Msg("Failed to persist rate limit record")
+	}
+}
+
+// evictLoop periodically removes identifiers that haven't attempted a
+// login in a while, so the bucket doesn't grow unbounded.
+func (l *BoltLimiter) evictLoop() {
+	ticker := time.NewTicker(evictInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-staleAfter)
+
+		err := l.db.Update(func(tx *bbolt.Tx) error {
+			bucket := tx.Bucket([]byte(attemptsBucket))
+			cursor := bucket.Cursor()
+
+			var stale [][]byte
+			for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+				var attempt types.LoginAttempt
+				if err := json.Unmarshal(v, &attempt); err != nil {
+					continue
+				}
+				if attempt.LastAttempt.Before(cutoff) {
+					stale = append(stale, append([]byte(nil), k...))
+				}
+			}
+
+			for _, identifier := range stale {
+				if err := bucket.Delete(identifier); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to evict stale rate limit records")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+	"tinyauth/internal/types"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MemoryLimiter is the legacy in-process backend: attempts are lost on
+// restart and the map grows unbounded for the lifetime of the process.
+type MemoryLimiter struct {
+	config  types.AuthConfig
+	mu      sync.RWMutex
+	attempt map[string]*types.LoginAttempt
+}
+
+// NewMemoryLimiter builds a MemoryLimiter from the auth config.
+func NewMemoryLimiter(config types.AuthConfig) *MemoryLimiter {
+	return &MemoryLimiter{
+		config:  config,
+		attempt: make(map[string]*types.LoginAttempt),
+	}
+}
+
+func (l *MemoryLimiter) IsLocked(identifier string) (bool, int) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.config.LoginMaxRetries <= 0 || l.config.LoginTimeout <= 0 {
+		return false, 0
+	}
+
+	attempt, exists := l.attempt[identifier]
+	if !exists {
+		return false, 0
+	}
+
+	if attempt.LockedUntil.After(time.Now()) {
+		return true, int(time.Until(attempt.LockedUntil).Seconds())
+	}
+
+	return false, 0
+}
+
+func (l *MemoryLimiter) RecordAttempt(identifier string, success bool) {
+	if l.config.LoginMaxRetries <= 0 || l.config.LoginTimeout <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	attempt, exists := l.attempt[identifier]
+	if !exists {
+		attempt = &types.LoginAttempt{}
+		l.attempt[identifier] = attempt
+	}
+
+	attempt.LastAttempt = time.Now()
+
+	if success {
+		attempt.FailedAttempts = 0
+		attempt.LockedUntil = time.Time{}
+		return
+	}
+
+	attempt.FailedAttempts++
+
+	if attempt.FailedAttempts >= l.config.LoginMaxRetries {
+		attempt.LockedUntil = time.Now().Add(time.Duration(l.config.LoginTimeout) * time.Second)
+		log.Warn().Str("identifier", identifier).Int("timeout", l.config.LoginTimeout).Msg("Account locked due to too many failed login attempts")
+	}
+}
@@ -0,0 +1,35 @@
+// Package ratelimit implements brute-force protection for login attempts,
+// independent of whatever calls into it (local users, LDAP, ...).
+package ratelimit
+
+import (
+	"fmt"
+	"tinyauth/internal/types"
+)
+
+// Limiter tracks failed login attempts per identifier (a username, or a
+// client IP) and decides when an identifier should be locked out.
+type Limiter interface {
+	// IsLocked reports whether identifier is currently locked out, and if
+	// so for how many more seconds.
+	IsLocked(identifier string) (locked bool, remainingSeconds int)
+
+	// RecordAttempt updates identifier's failure count. A successful
+	// attempt resets it; a failed attempt increments it and locks the
+	// identifier once LoginMaxRetries is reached.
+	RecordAttempt(identifier string, success bool)
+}
+
+// NewLimiter builds the configured Limiter backend. "memory" keeps the
+// legacy in-process map (lost on restart); "bolt" persists attempts to a
+// local BoltDB file so lockouts survive a tinyauth restart.
+func NewLimiter(config types.AuthConfig) (Limiter, error) {
+	switch config.RateLimitBackend {
+	case "", "memory":
+		return NewMemoryLimiter(config), nil
+	case "bolt":
+		return NewBoltLimiter(config)
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", config.RateLimitBackend)
+	}
+}
@@ -0,0 +1,428 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+	"tinyauth/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	sessionsBucket    = "sessions"
+	userSessionBucket = "user_sessions"
+
+	apiTokensBucket        = "api_tokens"
+	apiTokensByIDBucket    = "api_tokens_by_id"
+	apiTokensByOwnerBucket = "api_tokens_by_owner"
+
+	tokenBytes = 32
+
+	defaultMaxSessionsPerUser = 25
+	evictInterval             = time.Minute
+
+	// idleSlideThreshold bounds how often Get writes back a slid idle
+	// expiry. bbolt serializes all writers behind a single lock, and for a
+	// forward-auth proxy Get is the hottest path (one call per proxied
+	// request), so writing on every single read would serialize every
+	// concurrent auth check behind it. Only persist once the expiry has
+	// moved by more than this much.
+	idleSlideThreshold = 30 * time.Second
+)
+
+// record is what actually lives in BoltDB, keyed by the random token
+// carried in the cookie. Expiry is the current (possibly idle-slid)
+// expiry; Deadline is the absolute cap set once at Create and never
+// advanced, so idle sliding can't keep a session alive forever.
+type record struct {
+	Data     types.SessionCookie `json:"data"`
+	Username string              `json:"username"`
+	Expiry   time.Time           `json:"expiry"`
+	Deadline time.Time           `json:"deadline"`
+}
+
+// BoltStore is the server-side session backend: the cookie only carries a
+// random token, and the session payload is persisted in a local BoltDB
+// file keyed by that token. This makes "log out user X everywhere",
+// per-user session caps and idle timeouts possible, since tinyauth can see
+// and enumerate every active session.
+type BoltStore struct {
+	config types.AuthConfig
+	db     *bbolt.DB
+}
+
+// NewBoltStore opens (creating if necessary) the BoltDB file configured via
+// SessionBoltPath and starts the background expired-session evictor.
+func NewBoltStore(config types.AuthConfig) (*BoltStore, error) {
+	if config.SessionBoltPath == "" {
+		return nil, fmt.Errorf("SessionBoltPath must be set when SessionBackend is \"bolt\"")
+	}
+
+	db, err := bbolt.Open(config.SessionBoltPath, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session store: %w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range []string{sessionsBucket, userSessionBucket, apiTokensBucket, apiTokensByIDBucket, apiTokensByOwnerBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize session store buckets: %w", err)
+	}
+
+	store := &BoltStore{config: config, db: db}
+	go store.evictLoop()
+
+	return store, nil
+}
+
+func (s *BoltStore) maxPerUser() int {
+	if s.config.SessionMaxPerUser > 0 {
+		return s.config.SessionMaxPerUser
+	}
+	return defaultMaxSessionsPerUser
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (s *BoltStore) cookieDomain() string {
+	return fmt.Sprintf(".%s", s.config.Domain)
+}
+
+func (s *BoltStore) setCookie(c *gin.Context, token string, maxAge int) {
+	c.SetCookie(s.config.SessionCookieName, token, maxAge, "/", s.cookieDomain(), s.config.CookieSecure, true)
+}
+
+func (s *BoltStore) Create(c *gin.Context, data *types.SessionCookie) error {
+	token, err := newToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate session token: %w", err)
+	}
+
+	now := time.Now()
+	deadline := now.Add(sessionExpiry(s.config, data))
+
+	// With an idle timeout configured, a new session first expires after
+	// one idle window; Get slides it forward, up to deadline.
+	expiry := deadline
+	if s.config.SessionIdleExpiry > 0 {
+		if idle := now.Add(time.Duration(s.config.SessionIdleExpiry) * time.Second); idle.Before(deadline) {
+			expiry = idle
+		}
+	}
+
+	rec := record{
+		Data:     *data,
+		Username: data.Username,
+		Expiry:   expiry,
+		Deadline: deadline,
+	}
+
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket([]byte(sessionsBucket)).Put([]byte(token), payload); err != nil {
+			return err
+		}
+		return s.addUserToken(tx, data.Username, token)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to persist session: %w", err)
+	}
+
+	s.setCookie(c, token, int(time.Until(rec.Deadline).Seconds()))
+
+	return nil
+}
+
+// addUserToken appends token to username's session list, evicting the
+// oldest sessions if the per-user cap would be exceeded.
+func (s *BoltStore) addUserToken(tx *bbolt.Tx, username string, token string) error {
+	bucket := tx.Bucket([]byte(userSessionBucket))
+
+	var tokens []string
+	if raw := bucket.Get([]byte(username)); raw != nil {
+		if err := json.Unmarshal(raw, &tokens); err != nil {
+			return err
+		}
+	}
+
+	tokens = append(tokens, token)
+
+	if max := s.maxPerUser(); len(tokens) > max {
+		evicted := tokens[:len(tokens)-max]
+		tokens = tokens[len(tokens)-max:]
+
+		sessions := tx.Bucket([]byte(sessionsBucket))
+		for _, old := range evicted {
+			log.Debug().Str("username", username).Msg("Evicting oldest session to respect per-user session limit")
+			if err := sessions.Delete([]byte(old)); err != nil {
+				return err
+			}
+		}
+	}
+
+	payload, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(username), payload)
+}
+
+func (s *BoltStore) removeUserToken(tx *bbolt.Tx, username string, token string) error {
+	bucket := tx.Bucket([]byte(userSessionBucket))
+
+	raw := bucket.Get([]byte(username))
+	if raw == nil {
+		return nil
+	}
+
+	var tokens []string
+	if err := json.Unmarshal(raw, &tokens); err != nil {
+		return err
+	}
+
+	kept := tokens[:0]
+	for _, t := range tokens {
+		if t != token {
+			kept = append(kept, t)
+		}
+	}
+
+	if len(kept) == 0 {
+		return bucket.Delete([]byte(username))
+	}
+
+	payload, err := json.Marshal(kept)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(username), payload)
+}
+
+func (s *BoltStore) Get(c *gin.Context) (types.SessionCookie, bool, error) {
+	token, err := c.Cookie(s.config.SessionCookieName)
+	if err != nil || token == "" {
+		return types.SessionCookie{}, false, nil
+	}
+
+	var rec record
+	var found bool
+	now := time.Now()
+
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket([]byte(sessionsBucket)).Get([]byte(token))
+		if raw == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(raw, &rec)
+	})
+	if err != nil {
+		return types.SessionCookie{}, false, err
+	}
+
+	if !found {
+		return types.SessionCookie{}, false, nil
+	}
+
+	if now.After(rec.Expiry) {
+		log.Warn().Str("username", rec.Username).Msg("Session expired")
+		s.Delete(c)
+		return types.SessionCookie{}, false, nil
+	}
+
+	if s.config.SessionIdleExpiry > 0 {
+		// Accessing a valid session slides its expiry forward, so an idle
+		// session still times out on its own even if the browser keeps
+		// sending a cookie with a long MaxAge. The slide is capped at
+		// Deadline so an active session still hits its absolute lifetime,
+		// and only written back once it has moved by more than
+		// idleSlideThreshold to avoid a bbolt write transaction (which
+		// serializes all writers) on every single read.
+		newExpiry := now.Add(time.Duration(s.config.SessionIdleExpiry) * time.Second)
+		if newExpiry.After(rec.Deadline) {
+			newExpiry = rec.Deadline
+		}
+
+		if newExpiry.Sub(rec.Expiry) > idleSlideThreshold {
+			rec.Expiry = newExpiry
+
+			err = s.db.Update(func(tx *bbolt.Tx) error {
+				payload, err := json.Marshal(rec)
+				if err != nil {
+					return err
+				}
+				return tx.Bucket([]byte(sessionsBucket)).Put([]byte(token), payload)
+			})
+			if err != nil {
+				return types.SessionCookie{}, false, err
+			}
+		}
+	}
+
+	return rec.Data, true, nil
+}
+
+func (s *BoltStore) Delete(c *gin.Context) error {
+	token, err := c.Cookie(s.config.SessionCookieName)
+	if err == nil && token != "" {
+		err = s.db.Update(func(tx *bbolt.Tx) error {
+			raw := tx.Bucket([]byte(sessionsBucket)).Get([]byte(token))
+			if raw == nil {
+				return nil
+			}
+
+			var rec record
+			if err := json.Unmarshal(raw, &rec); err != nil {
+				return err
+			}
+
+			if err := tx.Bucket([]byte(sessionsBucket)).Delete([]byte(token)); err != nil {
+				return err
+			}
+
+			return s.removeUserToken(tx, rec.Username, token)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	s.setCookie(c, "", -1)
+
+	return nil
+}
+
+// DeleteAllForUser revokes every active session for username, e.g. for an
+// admin "log out user X everywhere" action.
+func (s *BoltStore) DeleteAllForUser(username string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(userSessionBucket))
+
+		raw := bucket.Get([]byte(username))
+		if raw == nil {
+			return nil
+		}
+
+		var tokens []string
+		if err := json.Unmarshal(raw, &tokens); err != nil {
+			return err
+		}
+
+		sessions := tx.Bucket([]byte(sessionsBucket))
+		for _, token := range tokens {
+			if err := sessions.Delete([]byte(token)); err != nil {
+				return err
+			}
+		}
+
+		return bucket.Delete([]byte(username))
+	})
+}
+
+// evictLoop periodically removes expired session records so the store
+// doesn't grow unbounded with abandoned sessions that were never
+// explicitly deleted.
+func (s *BoltStore) evictLoop() {
+	ticker := time.NewTicker(evictInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+
+		err := s.db.Update(func(tx *bbolt.Tx) error {
+			sessions := tx.Bucket([]byte(sessionsBucket))
+			cursor := sessions.Cursor()
+
+			var expired []record
+			var expiredTokens [][]byte
+			for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+				var rec record
+				if err := json.Unmarshal(v, &rec); err != nil {
+					continue
+				}
+				if now.After(rec.Expiry) {
+					expired = append(expired, rec)
+					expiredTokens = append(expiredTokens, append([]byte(nil), k...))
+				}
+			}
+
+			for i, token := range expiredTokens {
+				if err := sessions.Delete(token); err != nil {
+					return err
+				}
+				if err := s.removeUserToken(tx, expired[i].Username, string(token)); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to evict expired sessions")
+		}
+
+		if err := s.evictExpiredTokens(now); err != nil {
+			log.Warn().Err(err).Msg("Failed to evict expired API tokens")
+		}
+	}
+}
+
+// evictExpiredTokens removes API tokens past their ExpiresAt, along with
+// their owner-index entries, so a steady stream of short-lived tokens
+// doesn't grow the store unbounded.
+func (s *BoltStore) evictExpiredTokens(now time.Time) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		tokens := tx.Bucket([]byte(apiTokensBucket))
+		byID := tx.Bucket([]byte(apiTokensByIDBucket))
+		cursor := tokens.Cursor()
+
+		var expired []types.APIToken
+		for _, v := cursor.First(); v != nil; _, v = cursor.Next() {
+			var token types.APIToken
+			if err := json.Unmarshal(v, &token); err != nil {
+				continue
+			}
+			if !token.ExpiresAt.IsZero() && now.After(token.ExpiresAt) {
+				expired = append(expired, token)
+			}
+		}
+
+		for _, token := range expired {
+			if err := tokens.Delete([]byte(token.Hash)); err != nil {
+				return err
+			}
+			if err := byID.Delete([]byte(token.ID)); err != nil {
+				return err
+			}
+			if err := removeOwnerToken(tx, token.Owner, token.ID); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
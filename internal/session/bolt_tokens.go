@@ -0,0 +1,207 @@
+package session
+
+import (
+	"encoding/json"
+	"time"
+	"tinyauth/internal/types"
+
+	"go.etcd.io/bbolt"
+)
+
+// CreateToken persists token keyed by its Hash, and indexes it by ID (for
+// revocation) and by owner (for listing), mirroring the session/user
+// index pattern BoltStore uses for sessions (see addUserToken).
+func (s *BoltStore) CreateToken(token *types.APIToken) error {
+	payload, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket([]byte(apiTokensBucket)).Put([]byte(token.Hash), payload); err != nil {
+			return err
+		}
+
+		if err := tx.Bucket([]byte(apiTokensByIDBucket)).Put([]byte(token.ID), []byte(token.Hash)); err != nil {
+			return err
+		}
+
+		return addOwnerToken(tx, token.Owner, token.ID)
+	})
+}
+
+func addOwnerToken(tx *bbolt.Tx, owner string, id string) error {
+	bucket := tx.Bucket([]byte(apiTokensByOwnerBucket))
+
+	var ids []string
+	if raw := bucket.Get([]byte(owner)); raw != nil {
+		if err := json.Unmarshal(raw, &ids); err != nil {
+			return err
+		}
+	}
+
+	ids = append(ids, id)
+
+	payload, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(owner), payload)
+}
+
+func removeOwnerToken(tx *bbolt.Tx, owner string, id string) error {
+	bucket := tx.Bucket([]byte(apiTokensByOwnerBucket))
+
+	raw := bucket.Get([]byte(owner))
+	if raw == nil {
+		return nil
+	}
+
+	var ids []string
+	if err := json.Unmarshal(raw, &ids); err != nil {
+		return err
+	}
+
+	kept := ids[:0]
+	for _, existing := range ids {
+		if existing != id {
+			kept = append(kept, existing)
+		}
+	}
+
+	if len(kept) == 0 {
+		return bucket.Delete([]byte(owner))
+	}
+
+	payload, err := json.Marshal(kept)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(owner), payload)
+}
+
+func (s *BoltStore) LookupToken(hash string) (types.APIToken, bool, error) {
+	var token types.APIToken
+	var found bool
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket([]byte(apiTokensBucket)).Get([]byte(hash))
+		if raw == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(raw, &token)
+	})
+
+	return token, found, err
+}
+
+func (s *BoltStore) RevokeToken(id string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		byID := tx.Bucket([]byte(apiTokensByIDBucket))
+
+		hash := byID.Get([]byte(id))
+		if hash == nil {
+			return nil
+		}
+
+		raw := tx.Bucket([]byte(apiTokensBucket)).Get(hash)
+		var owner string
+		if raw != nil {
+			var token types.APIToken
+			if err := json.Unmarshal(raw, &token); err == nil {
+				owner = token.Owner
+			}
+		}
+
+		if err := tx.Bucket([]byte(apiTokensBucket)).Delete(hash); err != nil {
+			return err
+		}
+
+		if err := byID.Delete([]byte(id)); err != nil {
+			return err
+		}
+
+		if owner != "" {
+			return removeOwnerToken(tx, owner, id)
+		}
+
+		return nil
+	})
+}
+
+func (s *BoltStore) ListTokens(owner string) ([]types.APIToken, error) {
+	var tokens []types.APIToken
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket([]byte(apiTokensByOwnerBucket)).Get([]byte(owner))
+		if raw == nil {
+			return nil
+		}
+
+		var ids []string
+		if err := json.Unmarshal(raw, &ids); err != nil {
+			return err
+		}
+
+		byID := tx.Bucket([]byte(apiTokensByIDBucket))
+		tokensBucket := tx.Bucket([]byte(apiTokensBucket))
+
+		for _, id := range ids {
+			hash := byID.Get([]byte(id))
+			if hash == nil {
+				continue
+			}
+
+			raw := tokensBucket.Get(hash)
+			if raw == nil {
+				continue
+			}
+
+			var token types.APIToken
+			if err := json.Unmarshal(raw, &token); err != nil {
+				return err
+			}
+
+			tokens = append(tokens, token)
+		}
+
+		return nil
+	})
+
+	return tokens, err
+}
+
+func (s *BoltStore) TouchToken(id string, when time.Time) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		byID := tx.Bucket([]byte(apiTokensByIDBucket))
+
+		hash := byID.Get([]byte(id))
+		if hash == nil {
+			return nil
+		}
+
+		tokensBucket := tx.Bucket([]byte(apiTokensBucket))
+
+		raw := tokensBucket.Get(hash)
+		if raw == nil {
+			return nil
+		}
+
+		var token types.APIToken
+		if err := json.Unmarshal(raw, &token); err != nil {
+			return err
+		}
+
+		token.LastUsed = when
+
+		payload, err := json.Marshal(token)
+		if err != nil {
+			return err
+		}
+
+		return tokensBucket.Put(hash, payload)
+	})
+}
@@ -0,0 +1,129 @@
+package session
+
+import (
+	"fmt"
+	"time"
+	"tinyauth/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+	"github.com/rs/zerolog/log"
+)
+
+// CookieStore is the legacy backend: the full session payload is encrypted
+// and signed directly into the cookie, so there is nothing to look up
+// server-side. DeleteAllForUser cannot revoke sessions it doesn't know
+// about, so it is a no-op here.
+type CookieStore struct {
+	config types.AuthConfig
+	store  *sessions.CookieStore
+}
+
+// NewCookieStore builds a CookieStore backend from the auth config.
+func NewCookieStore(config types.AuthConfig) *CookieStore {
+	store := sessions.NewCookieStore([]byte(config.HMACSecret), []byte(config.EncryptionSecret))
+
+	store.Options = &sessions.Options{
+		Path:     "/",
+		MaxAge:   config.SessionExpiry,
+		Secure:   config.CookieSecure,
+		HttpOnly: true,
+		Domain:   fmt.Sprintf(".%s", config.Domain),
+	}
+
+	return &CookieStore{config: config, store: store}
+}
+
+func (s *CookieStore) getSession(c *gin.Context) (*sessions.Session, error) {
+	session, err := s.store.Get(c.Request, s.config.SessionCookieName)
+	if err != nil {
+		log.Warn().Err(err).Msg("Invalid session, clearing cookie and retrying")
+
+		c.SetCookie(s.config.SessionCookieName, "", -1, "/", fmt.Sprintf(".%s", s.config.Domain), s.config.CookieSecure, true)
+
+		session, err = s.store.Get(c.Request, s.config.SessionCookieName)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to get session")
+			return nil, err
+		}
+	}
+
+	return session, nil
+}
+
+func (s *CookieStore) Create(c *gin.Context, data *types.SessionCookie) error {
+	session, err := s.getSession(c)
+	if err != nil {
+		return err
+	}
+
+	session.Values["username"] = data.Username
+	session.Values["name"] = data.Name
+	session.Values["email"] = data.Email
+	session.Values["provider"] = data.Provider
+	session.Values["expiry"] = time.Now().Add(sessionExpiry(s.config, data)).Unix()
+	session.Values["totpPending"] = data.TotpPending
+	session.Values["oauthGroups"] = data.OAuthGroups
+	session.Values["ldapGroups"] = data.LDAPGroups
+
+	return session.Save(c.Request, c.Writer)
+}
+
+func (s *CookieStore) Get(c *gin.Context) (types.SessionCookie, bool, error) {
+	session, err := s.getSession(c)
+	if err != nil {
+		return types.SessionCookie{}, false, err
+	}
+
+	username, usernameOk := session.Values["username"].(string)
+	email, emailOk := session.Values["email"].(string)
+	name, nameOk := session.Values["name"].(string)
+	provider, providerOk := session.Values["provider"].(string)
+	expiry, expiryOk := session.Values["expiry"].(int64)
+	totpPending, totpPendingOk := session.Values["totpPending"].(bool)
+	oauthGroups, oauthGroupsOk := session.Values["oauthGroups"].(string)
+	// ldapGroups was added after this cookie format shipped, so a cookie
+	// minted before the upgrade won't have it. Treat that as "no groups"
+	// rather than invalidating an otherwise-valid session.
+	ldapGroups, _ := session.Values["ldapGroups"].(string)
+
+	if !usernameOk || !providerOk || !expiryOk || !totpPendingOk || !emailOk || !nameOk || !oauthGroupsOk {
+		log.Warn().Msg("Session cookie is invalid")
+		s.Delete(c)
+		return types.SessionCookie{}, false, nil
+	}
+
+	if time.Now().Unix() > expiry {
+		log.Warn().Msg("Session cookie expired")
+		s.Delete(c)
+		return types.SessionCookie{}, false, nil
+	}
+
+	return types.SessionCookie{
+		Username:    username,
+		Name:        name,
+		Email:       email,
+		Provider:    provider,
+		TotpPending: totpPending,
+		OAuthGroups: oauthGroups,
+		LDAPGroups:  ldapGroups,
+	}, true, nil
+}
+
+func (s *CookieStore) Delete(c *gin.Context) error {
+	session, err := s.getSession(c)
+	if err != nil {
+		return err
+	}
+
+	for key := range session.Values {
+		delete(session.Values, key)
+	}
+
+	return session.Save(c.Request, c.Writer)
+}
+
+func (s *CookieStore) DeleteAllForUser(username string) error {
+	log.Warn().Str("username", username).Msg("Cannot revoke sessions for user: cookie session backend does not keep server-side records, switch SessionBackend to \"bolt\"")
+	return nil
+}
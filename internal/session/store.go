@@ -0,0 +1,54 @@
+// Package session provides the server-side session storage used by Auth.
+// A Store is responsible for turning a types.SessionCookie into whatever
+// the browser actually carries (a self-contained cookie, or a bare token
+// pointing at a server-side record) and back.
+package session
+
+import (
+	"fmt"
+	"time"
+	"tinyauth/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Store is the abstraction Auth talks to for session persistence. It
+// replaces a bare *sessions.CookieStore so the backend can be swapped
+// between a pure cookie and a server-side record keyed by a token.
+type Store interface {
+	// Create starts a new session for data and writes whatever the
+	// backend needs onto the response (cookie, token, ...).
+	Create(c *gin.Context, data *types.SessionCookie) error
+
+	// Get reads back the session for the current request. ok is false if
+	// there is no valid, unexpired session.
+	Get(c *gin.Context) (data types.SessionCookie, ok bool, err error)
+
+	// Delete ends the current request's session.
+	Delete(c *gin.Context) error
+
+	// DeleteAllForUser revokes every session belonging to username,
+	// regardless of which browser/cookie created it. Used by the "log out
+	// user X everywhere" admin endpoint.
+	DeleteAllForUser(username string) error
+}
+
+// NewStore constructs the configured Store backend.
+func NewStore(config types.AuthConfig) (Store, error) {
+	switch config.SessionBackend {
+	case "", "cookie":
+		return NewCookieStore(config), nil
+	case "bolt":
+		return NewBoltStore(config)
+	default:
+		return nil, fmt.Errorf("unknown session backend %q", config.SessionBackend)
+	}
+}
+
+func sessionExpiry(config types.AuthConfig, data *types.SessionCookie) time.Duration {
+	if data.TotpPending {
+		return time.Hour
+	}
+
+	return time.Duration(config.SessionExpiry) * time.Second
+}
@@ -0,0 +1,29 @@
+package session
+
+import (
+	"time"
+	"tinyauth/internal/types"
+)
+
+// TokenStore is implemented by Store backends that can also persist API
+// tokens. Unlike sessions, tokens have no real meaning for the pure
+// CookieStore backend (there is nowhere server-side to keep them), so it
+// is a separate, optionally-implemented interface rather than part of
+// Store - callers should type-assert.
+type TokenStore interface {
+	// CreateToken persists token, keyed by its Hash.
+	CreateToken(token *types.APIToken) error
+
+	// LookupToken finds the token with the given hash, if any.
+	LookupToken(hash string) (token types.APIToken, ok bool, err error)
+
+	// RevokeToken deletes the token with the given ID.
+	RevokeToken(id string) error
+
+	// ListTokens returns every token owned by owner.
+	ListTokens(owner string) ([]types.APIToken, error)
+
+	// TouchToken updates the LastUsed timestamp for the token with the
+	// given ID.
+	TouchToken(id string, when time.Time) error
+}
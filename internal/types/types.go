@@ -0,0 +1,132 @@
+package types
+
+import "time"
+
+// User is a single local user configured via the tinyauth users list.
+type User struct {
+	Username string
+	Password string
+}
+
+// UserSearch is the result of looking up a username across the configured
+// user backends (local users, LDAP, ...).
+type UserSearch struct {
+	Username string
+	Type     string
+}
+
+// UserContext is the authenticated identity attached to a request after
+// auth has succeeded, regardless of which method produced it.
+type UserContext struct {
+	Username    string
+	Name        string
+	Email       string
+	Provider    string
+	OAuth       bool
+	OAuthGroups string
+	LDAPGroups  string
+	// Scopes is only populated when the request was authenticated via an
+	// APIToken; it is empty for session/basic-auth users.
+	Scopes []string
+}
+
+// APIToken is a long-lived, scoped credential that can authenticate
+// requests without a browser session, e.g. for scripting against a
+// tinyauth-protected service.
+type APIToken struct {
+	ID        string
+	Hash      string
+	Owner     string
+	Scopes    []string
+	ExpiresAt time.Time
+	LastUsed  time.Time
+}
+
+// SessionCookie is the user-facing session payload. It is the same shape
+// whether it is serialized directly into a cookie or kept server-side and
+// looked up by a token carried in the cookie.
+type SessionCookie struct {
+	Username    string
+	Name        string
+	Email       string
+	Provider    string
+	TotpPending bool
+	OAuthGroups string
+	LDAPGroups  string
+}
+
+// LoginAttempt tracks failed logins for a single identifier (username or
+// IP) for brute-force protection.
+type LoginAttempt struct {
+	FailedAttempts int
+	LastAttempt    time.Time
+	LockedUntil    time.Time
+}
+
+// OAuthLabels are the docker label derived settings for the OAuth path.
+type OAuthLabels struct {
+	Whitelist string
+	Groups    string
+}
+
+// IPLabels are the docker label derived allow/block lists for an IP filter.
+type IPLabels struct {
+	Allow []string
+	Block []string
+}
+
+// LDAPLabels are the docker label derived settings for per-container LDAP
+// group ACLs, e.g. tinyauth.ldap.groups.
+type LDAPLabels struct {
+	Groups string
+}
+
+// Labels is the set of tinyauth.* docker labels resolved for a protected
+// container/resource.
+type Labels struct {
+	Allowed string
+	Users   string
+	OAuth   OAuthLabels
+	LDAP    LDAPLabels
+	IP      IPLabels
+}
+
+// AuthConfig holds the configuration required to construct an Auth.
+type AuthConfig struct {
+	Users              []User
+	HMACSecret         string
+	EncryptionSecret   string
+	SessionExpiry      int
+	CookieSecure       bool
+	Domain             string
+	SessionCookieName  string
+	OauthWhitelist     string
+	LoginMaxRetries    int
+	LoginTimeout       int
+
+	// LDAPRequiredGroups, if non-empty, restricts login to LDAP users who
+	// are a member of at least one of these groups, regardless of any
+	// per-container tinyauth.ldap.groups label.
+	LDAPRequiredGroups []string
+
+	// SessionBackend selects where session data lives: "cookie" keeps the
+	// legacy self-contained encrypted cookie, "bolt" stores a random token
+	// in the cookie and the session data in a local BoltDB file.
+	SessionBackend    string
+	SessionBoltPath   string
+	SessionMaxPerUser int
+	SessionIdleExpiry int
+
+	// RateLimitBackend selects where brute-force attempt counters live:
+	// "memory" (default) keeps the legacy in-process map, "bolt" persists
+	// them to a local BoltDB file so lockouts survive a restart.
+	RateLimitBackend  string
+	RateLimitBoltPath string
+
+	// TrustedProxies lists the IPs/CIDRs of reverse proxies tinyauth sits
+	// behind. X-Forwarded-For/X-Real-Ip are only honored when the direct
+	// TCP peer is in this list; otherwise the connecting peer's address is
+	// used as-is. Used to derive the identifier rate limiting and login
+	// events key attempts by.
+	TrustedProxies []string
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"net"
+	"strings"
+)
+
+// CheckWhitelist returns true if item is present in a comma separated
+// whitelist, or if the whitelist is empty (meaning everyone is allowed).
+func CheckWhitelist(whitelist string, item string) bool {
+	if whitelist == "" {
+		return true
+	}
+
+	for _, entry := range strings.Split(whitelist, ",") {
+		if strings.TrimSpace(entry) == item {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FilterIP returns true if ip matches item, where item may be a single IP
+// address or a CIDR range.
+func FilterIP(item string, ip string) (bool, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false, nil
+	}
+
+	if !strings.Contains(item, "/") {
+		return net.ParseIP(item).Equal(parsed), nil
+	}
+
+	_, cidr, err := net.ParseCIDR(item)
+	if err != nil {
+		return false, err
+	}
+
+	return cidr.Contains(parsed), nil
+}